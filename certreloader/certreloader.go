@@ -0,0 +1,156 @@
+// Package certreloader keeps a tls.Certificate fresh on disk changes so
+// gRPC servers don't have to be restarted to pick up a rotated cert, which
+// previously dropped every in-flight ring-distribution stream.
+package certreloader
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	reloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "syndicate_cert_reload_total",
+		Help: "Count of certificate reload attempts by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(reloadsTotal)
+}
+
+// StatPollInterval is how often the reloader re-stats the cert/key files as
+// a fallback for filesystems where inotify isn't reliable (e.g. some
+// network mounts and overlayfs setups).
+const StatPollInterval = 30 * time.Second
+
+// Reloader watches a cert/key pair and atomically swaps in the latest
+// parsed tls.Certificate as it changes.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+}
+
+// New loads certFile/keyFile once and starts watching both for changes.
+// Call Close to stop the background watcher.
+func New(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		done:     make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("certreloader: creating watcher: %v", err)
+	}
+	if err := w.Add(certFile); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("certreloader: watching %s: %v", certFile, err)
+	}
+	if err := w.Add(keyFile); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("certreloader: watching %s: %v", keyFile, err)
+	}
+	r.watcher = w
+
+	go r.run()
+	return r, nil
+}
+
+// GetCertificate satisfies tls.Config.GetCertificate, reading the current
+// certificate on every handshake.
+func (r *Reloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Reload forces an immediate reload, used by the SIGHUP handler in main().
+func (r *Reloader) Reload() error {
+	return r.reload()
+}
+
+// Close stops the background watch goroutine.
+func (r *Reloader) Close() error {
+	close(r.done)
+	if r.watcher != nil {
+		return r.watcher.Close()
+	}
+	return nil
+}
+
+func (r *Reloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		reloadsTotal.WithLabelValues("failure").Inc()
+		return fmt.Errorf("certreloader: loading cert/key pair: %v", err)
+	}
+	r.cert.Store(&cert)
+	reloadsTotal.WithLabelValues("success").Inc()
+	return nil
+}
+
+func (r *Reloader) run() {
+	ticker := time.NewTicker(StatPollInterval)
+	defer ticker.Stop()
+	modTimes := func() (cert, key time.Time) {
+		if fi, err := os.Stat(r.certFile); err == nil {
+			cert = fi.ModTime()
+		}
+		if fi, err := os.Stat(r.keyFile); err == nil {
+			key = fi.ModTime()
+		}
+		return cert, key
+	}
+	lastCert, lastKey := modTimes()
+	for {
+		select {
+		case <-r.done:
+			return
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+				if err := r.reload(); err != nil {
+					log.Println(err)
+				}
+				// A Rename/Remove (as produced by a symlink-swap style
+				// rotation) drops the underlying inode from the watch;
+				// re-add it so future rotations keep firing events
+				// instead of silently falling back to the stat poll.
+				if event.Op&(fsnotify.Rename|fsnotify.Remove) != 0 {
+					if err := r.watcher.Add(event.Name); err != nil {
+						log.Println("certreloader: re-adding watch for", event.Name, ":", err)
+					}
+				}
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("certreloader: watcher error:", err)
+		case <-ticker.C:
+			certMod, keyMod := modTimes()
+			if certMod.Equal(lastCert) && keyMod.Equal(lastKey) {
+				continue
+			}
+			lastCert, lastKey = certMod, keyMod
+			if err := r.reload(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package consensus
+
+import "encoding/json"
+
+// encodeRingChange and decodeRingChange keep the on-the-wire log format
+// separate from the Raft plumbing in consensus.go; JSON is plenty for blobs that are
+// already written to disk as whole files.
+func encodeRingChange(change *RingChange) ([]byte, error) {
+	return json.Marshal(change)
+}
+
+func decodeRingChange(b []byte) (*RingChange, error) {
+	change := &RingChange{}
+	if err := json.Unmarshal(b, change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
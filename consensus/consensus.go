@@ -0,0 +1,248 @@
+// Package consensus replicates the ring/builder blobs across the syndicate
+// cluster using Raft, so any node can accept a ring update and leader
+// election is handled for us instead of hand-rolled master/slave pushes.
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+)
+
+// ErrNotLeader is returned by Apply when called against a node that is not
+// currently the Raft leader. Callers should redirect the request to
+// Leader() instead of retrying locally.
+var ErrNotLeader = errors.New("consensus: not the raft leader")
+
+// RingChange is the unit of work proposed to the Raft log. It carries the
+// full ring/builder blobs rather than a diff, mirroring how ringmgr already
+// keeps whole-file copies in memory.
+type RingChange struct {
+	Version int64
+	Ring    []byte
+	Builder []byte
+}
+
+// Config holds the knobs needed to stand up a Raft node for a syndicate
+// ringmgr instance.
+type Config struct {
+	// NodeID uniquely identifies this node within the Raft cluster. The
+	// slave's host:port is a reasonable default.
+	NodeID string
+	// RaftDir is where the Raft log, stable store and snapshots are kept.
+	RaftDir string
+	// BindAddr is the address Raft's transport listens on.
+	BindAddr string
+	// Bootstrap is true only for the node that forms a brand new cluster.
+	Bootstrap bool
+}
+
+// ApplyFunc is called on every node, leader or follower, once a RingChange
+// has been committed to the log. It's expected to be ringmgr's
+// applyRingChange.
+type ApplyFunc func(change *RingChange) error
+
+// Node wraps a raft.Raft instance and the FSM that drives ringmgr's state
+// from the replicated log.
+type Node struct {
+	raft *raft.Raft
+	fsm  *fsm
+}
+
+// New starts (or rejoins) a Raft node rooted at cfg.RaftDir and returns once
+// the transport and stores are ready. When cfg.Bootstrap is set, New also
+// bootstraps a single-voter cluster containing this node; otherwise the
+// returned Node is not a member of any cluster until the leader adds it
+// with Join.
+func New(cfg Config, apply ApplyFunc) (*Node, error) {
+	if err := os.MkdirAll(cfg.RaftDir, 0755); err != nil {
+		return nil, fmt.Errorf("consensus: creating raft dir: %v", err)
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp", cfg.BindAddr)
+	if err != nil {
+		return nil, fmt.Errorf("consensus: resolving bind addr: %v", err)
+	}
+	transport, err := raft.NewTCPTransport(cfg.BindAddr, addr, 3, 10*time.Second, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("consensus: creating transport: %v", err)
+	}
+
+	snapshots, err := raft.NewFileSnapshotStore(cfg.RaftDir, 2, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("consensus: creating snapshot store: %v", err)
+	}
+
+	store, err := raftboltdb.NewBoltStore(filepath.Join(cfg.RaftDir, "raft.db"))
+	if err != nil {
+		return nil, fmt.Errorf("consensus: creating bolt store: %v", err)
+	}
+
+	f := &fsm{apply: apply}
+
+	rc := raft.DefaultConfig()
+	rc.LocalID = raft.ServerID(cfg.NodeID)
+
+	r, err := raft.NewRaft(rc, f, store, store, snapshots, transport)
+	if err != nil {
+		return nil, fmt.Errorf("consensus: creating raft node: %v", err)
+	}
+
+	if cfg.Bootstrap {
+		cfgFuture := r.BootstrapCluster(raft.Configuration{
+			Servers: []raft.Server{
+				{ID: rc.LocalID, Address: transport.LocalAddr()},
+			},
+		})
+		if err := cfgFuture.Error(); err != nil {
+			return nil, fmt.Errorf("consensus: bootstrapping cluster: %v", err)
+		}
+	}
+
+	return &Node{raft: r, fsm: f}, nil
+}
+
+// Apply proposes a RingChange to the cluster and blocks until it's been
+// committed by quorum, replacing the old "more than half of slaves failed"
+// fatal check with a proper quorum acknowledgement.
+func (n *Node) Apply(change *RingChange, timeout time.Duration) error {
+	if n.raft.State() != raft.Leader {
+		return ErrNotLeader
+	}
+	b, err := encodeRingChange(change)
+	if err != nil {
+		return err
+	}
+	f := n.raft.Apply(b, timeout)
+	if err := f.Error(); err != nil {
+		return fmt.Errorf("consensus: apply failed: %v", err)
+	}
+	if err, ok := f.Response().(error); ok && err != nil {
+		return err
+	}
+	return nil
+}
+
+// Join adds addr as a voter in the cluster. It must be called against the
+// current leader; use Leader to find it first.
+func (n *Node) Join(nodeID, addr string) error {
+	if n.raft.State() != raft.Leader {
+		return ErrNotLeader
+	}
+	f := n.raft.AddVoter(raft.ServerID(nodeID), raft.ServerAddress(addr), 0, 0)
+	return f.Error()
+}
+
+// Leave removes nodeID from the cluster's voter set.
+func (n *Node) Leave(nodeID string) error {
+	if n.raft.State() != raft.Leader {
+		return ErrNotLeader
+	}
+	f := n.raft.RemoveServer(raft.ServerID(nodeID), 0, 0)
+	return f.Error()
+}
+
+// TransferLeadership hands off leadership to another voter, used by the
+// gRPC TransferLeadership RPC so operators can drain a node before
+// maintenance.
+func (n *Node) TransferLeadership() error {
+	f := n.raft.LeadershipTransfer()
+	return f.Error()
+}
+
+// IsLeader reports whether this node is currently the Raft leader.
+func (n *Node) IsLeader() bool {
+	return n.raft.State() == raft.Leader
+}
+
+// Leader returns the address of the current leader, if known.
+func (n *Node) Leader() string {
+	return string(n.raft.Leader())
+}
+
+// fsm implements raft.FSM by delegating committed RingChanges to ringmgr via
+// the ApplyFunc supplied to New. It also tracks the most recently applied
+// RingChange so Snapshot has something to persist: a late joiner or a node
+// restoring past log compaction only ever sees an InstallSnapshot, not the
+// log entries that produced it, so the ring/builder blobs have to travel in
+// the snapshot rather than being assumed already present on local disk.
+type fsm struct {
+	apply ApplyFunc
+
+	mu   sync.Mutex
+	last *RingChange
+}
+
+func (f *fsm) Apply(l *raft.Log) interface{} {
+	change, err := decodeRingChange(l.Data)
+	if err != nil {
+		return err
+	}
+	if err := f.apply(change); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.last = change
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &ringSnapshot{change: f.last}, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	change, err := decodeRingChange(b)
+	if err != nil {
+		return err
+	}
+	if err := f.apply(change); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.last = change
+	f.mu.Unlock()
+	return nil
+}
+
+// ringSnapshot persists the fsm's last applied RingChange so InstallSnapshot
+// can reconstruct a node that never saw the log entries that produced it.
+type ringSnapshot struct {
+	change *RingChange
+}
+
+func (s *ringSnapshot) Persist(sink raft.SnapshotSink) error {
+	if s.change == nil {
+		return sink.Close()
+	}
+	b, err := encodeRingChange(s.change)
+	if err != nil {
+		sink.Cancel()
+		return err
+	}
+	if _, err := sink.Write(b); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *ringSnapshot) Release() {}
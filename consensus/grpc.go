@@ -0,0 +1,52 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/pandemicsyn/syndicate/api/proto"
+)
+
+// Server adapts a Node to the JoinCluster/LeaveCluster/TransferLeadership
+// RPCs so an operator can grow, shrink, or drain the Raft cluster without
+// restarting a node.
+type Server struct {
+	node *Node
+}
+
+// NewServer wraps node for gRPC registration.
+func NewServer(node *Node) *Server {
+	return &Server{node: node}
+}
+
+// JoinCluster adds the requesting node as a Raft voter. It must be called
+// against the leader; a non-leader reports the current leader back so the
+// caller can retry there.
+func (s *Server) JoinCluster(ctx context.Context, req *pb.JoinClusterRequest) (*pb.JoinClusterResponse, error) {
+	if err := s.node.Join(req.NodeID, req.Addr); err != nil {
+		if err == ErrNotLeader {
+			// gRPC drops the response message when an error is returned,
+			// so the leader has to travel in the error itself.
+			return nil, fmt.Errorf("%v, current leader is %s", err, s.node.Leader())
+		}
+		return nil, err
+	}
+	return &pb.JoinClusterResponse{Leader: s.node.Leader()}, nil
+}
+
+// LeaveCluster removes the requesting node from the Raft voter set.
+func (s *Server) LeaveCluster(ctx context.Context, req *pb.LeaveClusterRequest) (*pb.LeaveClusterResponse, error) {
+	if err := s.node.Leave(req.NodeID); err != nil {
+		return nil, err
+	}
+	return &pb.LeaveClusterResponse{}, nil
+}
+
+// TransferLeadership hands Raft leadership to another voter, so an
+// operator can drain this node before maintenance.
+func (s *Server) TransferLeadership(ctx context.Context, req *pb.TransferLeadershipRequest) (*pb.TransferLeadershipResponse, error) {
+	if err := s.node.TransferLeadership(); err != nil {
+		return nil, err
+	}
+	return &pb.TransferLeadershipResponse{}, nil
+}
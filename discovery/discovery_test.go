@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name        string
+		current     []string
+		resolved    []string
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{"no change", []string{"a", "b"}, []string{"a", "b"}, nil, nil},
+		{"added only", []string{"a"}, []string{"a", "b"}, []string{"b"}, nil},
+		{"removed only", []string{"a", "b"}, []string{"a"}, nil, []string{"b"}},
+		{"added and removed", []string{"a", "b"}, []string{"b", "c"}, []string{"c"}, []string{"a"}},
+		{"empty current", nil, []string{"a"}, []string{"a"}, nil},
+		{"empty resolved", []string{"a"}, nil, nil, []string{"a"}},
+	}
+	for _, c := range cases {
+		added, removed := Diff(c.current, c.resolved)
+		sort.Strings(added)
+		sort.Strings(removed)
+		if !reflect.DeepEqual(added, c.wantAdded) {
+			t.Errorf("%s: added = %v, want %v", c.name, added, c.wantAdded)
+		}
+		if !reflect.DeepEqual(removed, c.wantRemoved) {
+			t.Errorf("%s: removed = %v, want %v", c.name, removed, c.wantRemoved)
+		}
+	}
+}
+
+func TestHealthTrackerUnseenDefaultsHealthy(t *testing.T) {
+	h := NewHealthTracker(3)
+	if !h.IsHealthy("slave1") {
+		t.Fatal("unseen slave should default to healthy")
+	}
+}
+
+func TestHealthTrackerBecomesUnhealthyAtThreshold(t *testing.T) {
+	h := NewHealthTracker(3)
+	if became := h.RecordFailure("slave1"); became {
+		t.Fatal("1st failure should not cross threshold 3")
+	}
+	if became := h.RecordFailure("slave1"); became {
+		t.Fatal("2nd failure should not cross threshold 3")
+	}
+	if !h.IsHealthy("slave1") {
+		t.Fatal("slave1 should still be healthy before the 3rd failure")
+	}
+	if became := h.RecordFailure("slave1"); !became {
+		t.Fatal("3rd failure should cross threshold 3")
+	}
+	if h.IsHealthy("slave1") {
+		t.Fatal("slave1 should be unhealthy after crossing the threshold")
+	}
+	// Further failures past the threshold shouldn't re-report a transition.
+	if became := h.RecordFailure("slave1"); became {
+		t.Fatal("failure after already-unhealthy should not re-report a transition")
+	}
+}
+
+func TestHealthTrackerRecordSuccessResets(t *testing.T) {
+	h := NewHealthTracker(1)
+	h.RecordFailure("slave1")
+	if h.IsHealthy("slave1") {
+		t.Fatal("slave1 should be unhealthy after a failure at threshold 1")
+	}
+	h.RecordSuccess("slave1")
+	if !h.IsHealthy("slave1") {
+		t.Fatal("slave1 should be healthy again after RecordSuccess")
+	}
+	if became := h.RecordFailure("slave1"); !became {
+		t.Fatal("failure after RecordSuccess reset should again cross the threshold")
+	}
+}
+
+func TestHealthTrackerRemove(t *testing.T) {
+	h := NewHealthTracker(1)
+	h.RecordFailure("slave1")
+	h.Remove("slave1")
+	if !h.IsHealthy("slave1") {
+		t.Fatal("removed slave should default back to healthy")
+	}
+	for _, s := range h.Snapshot() {
+		if s.Slave == "slave1" {
+			t.Fatalf("removed slave1 should not appear in Snapshot, got %+v", h.Snapshot())
+		}
+	}
+}
+
+func TestHealthTrackerSnapshot(t *testing.T) {
+	h := NewHealthTracker(1)
+	h.RecordSuccess("slave1")
+	h.RecordFailure("slave2")
+
+	got := make(map[string]bool)
+	for _, s := range h.Snapshot() {
+		got[s.Slave] = s.Healthy
+	}
+	want := map[string]bool{"slave1": true, "slave2": false}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Snapshot() = %v, want %v", got, want)
+	}
+}
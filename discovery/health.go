@@ -0,0 +1,103 @@
+package discovery
+
+import "sync"
+
+// DefaultUnhealthyThreshold is how many consecutive push failures mark a
+// slave unhealthy if a Config doesn't override it.
+const DefaultUnhealthyThreshold = 3
+
+// HealthTracker counts consecutive push failures per slave and reports
+// whether a slave should be considered unhealthy, replacing the old
+// fatal-on->50%-failed-at-startup behavior with per-slave degradation.
+type HealthTracker struct {
+	threshold int
+
+	mu       sync.Mutex
+	failures map[string]int
+	healthy  map[string]bool
+}
+
+// NewHealthTracker returns a tracker that marks a slave unhealthy after
+// threshold consecutive failures (DefaultUnhealthyThreshold if zero).
+func NewHealthTracker(threshold int) *HealthTracker {
+	if threshold == 0 {
+		threshold = DefaultUnhealthyThreshold
+	}
+	return &HealthTracker{
+		threshold: threshold,
+		failures:  make(map[string]int),
+		healthy:   make(map[string]bool),
+	}
+}
+
+// RecordSuccess resets a slave's failure count and marks it healthy.
+func (h *HealthTracker) RecordSuccess(slave string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures[slave] = 0
+	h.healthy[slave] = true
+}
+
+// RecordFailure increments a slave's consecutive failure count and returns
+// whether it just crossed the unhealthy threshold.
+func (h *HealthTracker) RecordFailure(slave string) (becameUnhealthy bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures[slave]++
+	wasHealthy, seen := h.healthy[slave]
+	if !seen {
+		// Unseen defaults to healthy, matching IsHealthy, so a slave's
+		// very first unhealthy transition is still reported.
+		wasHealthy = true
+	}
+	if h.failures[slave] >= h.threshold {
+		h.healthy[slave] = false
+		return wasHealthy
+	}
+	return false
+}
+
+// IsHealthy reports a slave's last known health; unseen slaves, and slaves
+// with failures still below the threshold, default to healthy so a brand
+// new registration isn't immediately flagged.
+func (h *HealthTracker) IsHealthy(slave string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	healthy, ok := h.healthy[slave]
+	if !ok {
+		return true
+	}
+	return healthy
+}
+
+// Remove drops a slave's tracked state, used on DeregisterSlave.
+func (h *HealthTracker) Remove(slave string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.failures, slave)
+	delete(h.healthy, slave)
+}
+
+// Status is a point-in-time membership + health snapshot for the
+// ClusterStatus RPC.
+type Status struct {
+	Slave   string
+	Healthy bool
+}
+
+// Snapshot returns the current health of every tracked slave.
+func (h *HealthTracker) Snapshot() []Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]Status, 0, len(h.failures))
+	for slave := range h.failures {
+		healthy, ok := h.healthy[slave]
+		if !ok {
+			// Same default as IsHealthy: below-threshold failures alone
+			// don't make a slave unhealthy.
+			healthy = true
+		}
+		out = append(out, Status{Slave: slave, Healthy: healthy})
+	}
+	return out
+}
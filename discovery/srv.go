@@ -0,0 +1,75 @@
+// Package discovery resolves the SRV-based slave membership list so the
+// master doesn't need a static, restart-to-edit Slaves list in config.
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// DefaultInterval is how often Watcher re-resolves the SRV record when the
+// config doesn't specify one.
+const DefaultInterval = 30
+
+// Resolver is the subset of net's SRV lookup Watcher needs; it exists so
+// tests can substitute a fake without hitting real DNS.
+type Resolver interface {
+	LookupSRV(service, proto, name string) (cname string, addrs []*net.SRV, err error)
+}
+
+type netResolver struct{}
+
+func (netResolver) LookupSRV(service, proto, name string) (string, []*net.SRV, error) {
+	return net.LookupSRV(service, proto, name)
+}
+
+// Resolve looks up "_syndicate-slave._tcp.<domain>" and returns the
+// "host:port" endpoint set, sorted for stable diffing.
+func Resolve(resolver Resolver, domain string) ([]string, error) {
+	_, srvs, err := resolver.LookupSRV("syndicate-slave", "tcp", domain)
+	if err != nil {
+		return nil, fmt.Errorf("discovery: SRV lookup for %s failed: %v", domain, err)
+	}
+	endpoints := make([]string, 0, len(srvs))
+	for _, srv := range srvs {
+		endpoints = append(endpoints, fmt.Sprintf("%s:%d", trimTrailingDot(srv.Target), srv.Port))
+	}
+	sort.Strings(endpoints)
+	return endpoints, nil
+}
+
+func trimTrailingDot(host string) string {
+	if len(host) > 0 && host[len(host)-1] == '.' {
+		return host[:len(host)-1]
+	}
+	return host
+}
+
+// Diff compares the current membership against a freshly resolved set and
+// returns the endpoints to add and remove.
+func Diff(current, resolved []string) (added, removed []string) {
+	curSet := make(map[string]bool, len(current))
+	for _, c := range current {
+		curSet[c] = true
+	}
+	resSet := make(map[string]bool, len(resolved))
+	for _, r := range resolved {
+		resSet[r] = true
+		if !curSet[r] {
+			added = append(added, r)
+		}
+	}
+	for _, c := range current {
+		if !resSet[c] {
+			removed = append(removed, c)
+		}
+	}
+	return added, removed
+}
+
+// NewResolver returns the real net.DefaultResolver-backed Resolver used in
+// production; callers in tests supply their own.
+func NewResolver() Resolver {
+	return netResolver{}
+}
@@ -0,0 +1,62 @@
+package discovery
+
+import "time"
+
+// Watcher periodically re-resolves a domain's SRV record and reports the
+// diff against the caller's current membership.
+type Watcher struct {
+	resolver Resolver
+	domain   string
+	interval time.Duration
+	current  func() []string
+	done     chan struct{}
+}
+
+// NewWatcher starts watching domain for membership changes every interval
+// seconds (DefaultInterval if zero). current is called on every tick to
+// get the caller's authoritative membership list, so a peer that fails to
+// register (and is therefore never added to the caller's list) keeps
+// showing up as "added" on the next tick instead of being silently
+// dropped.
+func NewWatcher(resolver Resolver, domain string, intervalSeconds int, current func() []string) *Watcher {
+	if intervalSeconds == 0 {
+		intervalSeconds = DefaultInterval
+	}
+	return &Watcher{
+		resolver: resolver,
+		domain:   domain,
+		interval: time.Duration(intervalSeconds) * time.Second,
+		current:  current,
+		done:     make(chan struct{}),
+	}
+}
+
+// Run polls on Watcher's interval until Stop is called, invoking onChange
+// with the added/removed endpoints whenever the resolved set differs from
+// the caller's current membership. onChange is expected to call
+// RegisterSlave/DeregisterSlave under the caller's own membership mutex and
+// only persist peers that actually succeeded.
+func (w *Watcher) Run(onChange func(added, removed []string)) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			resolved, err := Resolve(w.resolver, w.domain)
+			if err != nil {
+				continue
+			}
+			added, removed := Diff(w.current(), resolved)
+			if len(added) != 0 || len(removed) != 0 {
+				onChange(added, removed)
+			}
+		}
+	}
+}
+
+// Stop ends the watch loop.
+func (w *Watcher) Stop() {
+	close(w.done)
+}
@@ -0,0 +1,139 @@
+// Package ftls builds the tls.Config used by the various oort services
+// from a single shared Config struct, so both sides of a gRPC connection
+// (server and client) get the same cert reloading and mutual-TLS behavior
+// instead of each service hand-rolling its own TLS bootstrap.
+package ftls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/pandemicsyn/syndicate/certreloader"
+)
+
+// Config describes how a service should set up TLS, for both its gRPC
+// server and any outbound gRPC dials it makes.
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// CAFile, if set, is used to verify the peer's certificate; on the
+	// server side it is the pool client certs are checked against when
+	// ClientAuth asks for verification (setting CAFile alone does not
+	// request a client cert), on the client side it verifies the server.
+	CAFile string
+	// ClientAuth controls whether/how a server requires a client cert.
+	// Defaults to tls.NoClientCert if unset.
+	ClientAuth tls.ClientAuthType
+	// SkipVerify disables peer certificate verification. Only meant for
+	// local development; never set this in production config.
+	SkipVerify bool
+	// ServerName overrides the server name used for client-side
+	// verification, for cases where it doesn't match the dial address.
+	ServerName string
+}
+
+// MutualTLS reports whether cfg requires clients to present a certificate,
+// either verified (RequireAndVerifyClientCert) or not (RequireAnyClientCert).
+func (cfg *Config) MutualTLS() bool {
+	return cfg.ClientAuth == tls.RequireAndVerifyClientCert || cfg.ClientAuth == tls.RequireAnyClientCert
+}
+
+func (cfg *Config) caPool() (*x509.CertPool, error) {
+	if cfg.CAFile == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("ftls: reading CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ftls: no certificates found in %s", cfg.CAFile)
+	}
+	return pool, nil
+}
+
+// ServerOption builds a grpc.ServerOption from cfg, with the served
+// certificate kept fresh via certreloader and, when CAFile is set and
+// ClientAuth asks for verification, client certs verified against it.
+func ServerOption(cfg *Config) (grpc.ServerOption, *certreloader.Reloader, error) {
+	reloader, err := certreloader.New(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	pool, err := cfg.caPool()
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsCfg := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		ClientCAs:      pool,
+		ClientAuth:     cfg.ClientAuth,
+	}
+	return grpc.Creds(credentials.NewTLS(tlsCfg)), reloader, nil
+}
+
+// DialOption builds a grpc.DialOption from cfg for clients connecting to a
+// syndicate service, e.g. a slave dialing the master to register.
+func DialOption(cfg *Config) (grpc.DialOption, error) {
+	pool, err := cfg.caPool()
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg := &tls.Config{
+		RootCAs:            pool,
+		InsecureSkipVerify: cfg.SkipVerify,
+		ServerName:         cfg.ServerName,
+	}
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		reloader, err := certreloader.New(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.GetCertificate(nil)
+		}
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)), nil
+}
+
+// VerifyPeerIdentity checks that one of the verified chains presented by a
+// client cert has a CN, DNS SAN, or IP SAN matching the host portion of
+// expectedAddr (typically the slave's registered host:port address, which
+// a cert's identity never includes the port for). synd's RegisterSlave
+// interceptor calls this before the RegisterSlave handler accepts a new peer.
+func VerifyPeerIdentity(state tls.ConnectionState, expectedAddr string) error {
+	host, _, err := net.SplitHostPort(expectedAddr)
+	if err != nil {
+		host = expectedAddr
+	}
+	ip := net.ParseIP(host)
+	for _, chain := range state.VerifiedChains {
+		if len(chain) == 0 {
+			continue
+		}
+		leaf := chain[0]
+		if leaf.Subject.CommonName == host {
+			return nil
+		}
+		for _, name := range leaf.DNSNames {
+			if name == host {
+				return nil
+			}
+		}
+		if ip != nil {
+			for _, leafIP := range leaf.IPAddresses {
+				if leafIP.Equal(ip) {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("ftls: no verified certificate matches identity %q", host)
+}
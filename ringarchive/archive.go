@@ -0,0 +1,196 @@
+// Package ringarchive keeps a timestamped history of every ring/builder
+// pair the cluster has applied, so operators can inspect past versions and
+// roll back a bad ring without hand-editing files in RingDir.
+package ringarchive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxArchived is used when a Config doesn't set MaxArchived.
+const DefaultMaxArchived = 50
+
+// Config controls how aggressively the archive is pruned.
+type Config struct {
+	RingDir string
+	// MaxArchived is the number of archived ring/builder pairs to keep,
+	// regardless of age. Zero means DefaultMaxArchived.
+	MaxArchived int
+	// MaxArchiveAge, if non-zero, additionally prunes any archive older
+	// than this, even if MaxArchived hasn't been reached yet.
+	MaxArchiveAge time.Duration
+}
+
+// Entry describes one archived ring/builder pair.
+type Entry struct {
+	Version     int64
+	Timestamp   time.Time
+	RingPath    string
+	BuilderPath string
+}
+
+// Archive writes and prunes timestamped ring/builder archives under
+// cfg.RingDir.
+type Archive struct {
+	cfg Config
+}
+
+// New returns an Archive for cfg. cfg.RingDir must already exist.
+func New(cfg Config) *Archive {
+	if cfg.MaxArchived == 0 {
+		cfg.MaxArchived = DefaultMaxArchived
+	}
+	return &Archive{cfg: cfg}
+}
+
+// Record is called after every successful applyRingChange. It writes
+// <unixnano>-<version>-oort.ring and the matching .builder file, then
+// prunes the archive down to the configured retention.
+func (a *Archive) Record(version int64, ringBytes, builderBytes []byte, now time.Time) (Entry, error) {
+	stamp := now.UnixNano()
+	ringPath := filepath.Join(a.cfg.RingDir, fmt.Sprintf("%d-%d-oort.ring", stamp, version))
+	builderPath := filepath.Join(a.cfg.RingDir, fmt.Sprintf("%d-%d-oort.builder", stamp, version))
+
+	if err := os.WriteFile(ringPath, ringBytes, 0644); err != nil {
+		return Entry{}, fmt.Errorf("ringarchive: writing %s: %v", ringPath, err)
+	}
+	if err := os.WriteFile(builderPath, builderBytes, 0644); err != nil {
+		return Entry{}, fmt.Errorf("ringarchive: writing %s: %v", builderPath, err)
+	}
+
+	entry := Entry{Version: version, Timestamp: now, RingPath: ringPath, BuilderPath: builderPath}
+
+	if err := a.prune(now); err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// List returns every archived entry, oldest first.
+func (a *Archive) List() ([]Entry, error) {
+	fp, err := os.Open(a.cfg.RingDir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := fp.Readdirnames(-1)
+	fp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0)
+	for _, name := range names {
+		if !strings.HasSuffix(name, "-oort.ring") {
+			continue
+		}
+		stamp, version, ok := parseArchiveName(name, "-oort.ring")
+		if !ok {
+			continue
+		}
+		builder := fmt.Sprintf("%d-%d-oort.builder", stamp, version)
+		entries = append(entries, Entry{
+			Version:     version,
+			Timestamp:   time.Unix(0, stamp),
+			RingPath:    filepath.Join(a.cfg.RingDir, name),
+			BuilderPath: filepath.Join(a.cfg.RingDir, builder),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	return entries, nil
+}
+
+// Get returns the archived entry for the given ring version, if any.
+func (a *Archive) Get(version int64) (Entry, error) {
+	entries, err := a.List()
+	if err != nil {
+		return Entry{}, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Version == version {
+			return entries[i], nil
+		}
+	}
+	return Entry{}, fmt.Errorf("ringarchive: no archived version %d in %s", version, a.cfg.RingDir)
+}
+
+// Rollback copies the archived ring/builder pair for version back into the
+// active oort.ring/oort.builder files, returning the restored Entry.
+func (a *Archive) Rollback(version int64) (Entry, error) {
+	entry, err := a.Get(version)
+	if err != nil {
+		return Entry{}, err
+	}
+	ringBytes, err := os.ReadFile(entry.RingPath)
+	if err != nil {
+		return Entry{}, err
+	}
+	builderBytes, err := os.ReadFile(entry.BuilderPath)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := os.WriteFile(filepath.Join(a.cfg.RingDir, "oort.ring"), ringBytes, 0644); err != nil {
+		return Entry{}, err
+	}
+	if err := os.WriteFile(filepath.Join(a.cfg.RingDir, "oort.builder"), builderBytes, 0644); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+func (a *Archive) prune(now time.Time) error {
+	entries, err := a.List()
+	if err != nil {
+		return err
+	}
+
+	keep := entries
+	if a.cfg.MaxArchiveAge > 0 {
+		fresh := entries[:0:0]
+		for _, e := range entries {
+			if now.Sub(e.Timestamp) <= a.cfg.MaxArchiveAge {
+				fresh = append(fresh, e)
+			} else {
+				a.remove(e)
+			}
+		}
+		keep = fresh
+	}
+
+	if len(keep) > a.cfg.MaxArchived {
+		excess := keep[:len(keep)-a.cfg.MaxArchived]
+		for _, e := range excess {
+			a.remove(e)
+		}
+	}
+	return nil
+}
+
+func (a *Archive) remove(e Entry) {
+	os.Remove(e.RingPath)
+	os.Remove(e.BuilderPath)
+}
+
+// parseArchiveName splits a "<unixnano>-<version>-oort.ring"-style name
+// into its timestamp and version components.
+func parseArchiveName(name, suffix string) (stamp int64, version int64, ok bool) {
+	trimmed := strings.TrimSuffix(name, suffix)
+	parts := strings.SplitN(trimmed, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	stamp, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	version, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return stamp, version, true
+}
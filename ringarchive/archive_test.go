@@ -0,0 +1,98 @@
+package ringarchive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseArchiveName(t *testing.T) {
+	cases := []struct {
+		name        string
+		suffix      string
+		wantStamp   int64
+		wantVersion int64
+		wantOK      bool
+	}{
+		{"1000-7-oort.ring", "-oort.ring", 1000, 7, true},
+		{"1000-7-oort.builder", "-oort.builder", 1000, 7, true},
+		{"oort.ring", "-oort.ring", 0, 0, false},
+		{"1000-oort.ring", "-oort.ring", 0, 0, false},
+		{"abc-7-oort.ring", "-oort.ring", 0, 0, false},
+		{"1000-abc-oort.ring", "-oort.ring", 0, 0, false},
+	}
+	for _, c := range cases {
+		stamp, version, ok := parseArchiveName(c.name, c.suffix)
+		if ok != c.wantOK || stamp != c.wantStamp || version != c.wantVersion {
+			t.Errorf("parseArchiveName(%q, %q) = (%d, %d, %v), want (%d, %d, %v)",
+				c.name, c.suffix, stamp, version, ok, c.wantStamp, c.wantVersion, c.wantOK)
+		}
+	}
+}
+
+func TestArchiveRecordPrunesByCount(t *testing.T) {
+	dir := t.TempDir()
+	a := New(Config{RingDir: dir, MaxArchived: 2})
+
+	base := time.Unix(0, 1700000000000000000)
+	for i := int64(1); i <= 3; i++ {
+		if _, err := a.Record(i, []byte("ring"), []byte("builder"), base.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("Record(%d): %v", i, err)
+		}
+	}
+
+	entries, err := a.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("List() returned %d entries, want 2 (MaxArchived)", len(entries))
+	}
+	if entries[0].Version != 2 || entries[1].Version != 3 {
+		t.Fatalf("List() = %+v, want versions [2 3] (oldest pruned)", entries)
+	}
+}
+
+func TestArchiveRecordPrunesByAge(t *testing.T) {
+	dir := t.TempDir()
+	a := New(Config{RingDir: dir, MaxArchived: 10, MaxArchiveAge: time.Minute})
+
+	now := time.Unix(0, 1700000000000000000)
+	old := now.Add(-time.Hour)
+	if _, err := a.Record(1, []byte("ring"), []byte("builder"), old); err != nil {
+		t.Fatalf("Record(1): %v", err)
+	}
+	if _, err := a.Record(2, []byte("ring"), []byte("builder"), now); err != nil {
+		t.Fatalf("Record(2): %v", err)
+	}
+
+	entries, err := a.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Version != 2 {
+		t.Fatalf("List() = %+v, want only version 2 (version 1 older than MaxArchiveAge)", entries)
+	}
+}
+
+func TestArchiveRollback(t *testing.T) {
+	dir := t.TempDir()
+	a := New(Config{RingDir: dir})
+
+	if _, err := a.Record(1, []byte("ring-v1"), []byte("builder-v1"), time.Unix(0, 1)); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	if _, err := a.Rollback(1); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	ring, err := os.ReadFile(filepath.Join(dir, "oort.ring"))
+	if err != nil {
+		t.Fatalf("reading restored oort.ring: %v", err)
+	}
+	if string(ring) != "ring-v1" {
+		t.Fatalf("restored oort.ring = %q, want %q", ring, "ring-v1")
+	}
+}
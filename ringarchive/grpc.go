@@ -0,0 +1,53 @@
+package ringarchive
+
+import (
+	"context"
+
+	pb "github.com/pandemicsyn/syndicate/api/proto"
+)
+
+// Server adapts an Archive to gRPC so operators can list, inspect, and
+// roll back archived ring versions remotely instead of reading RingDir by
+// hand.
+type Server struct {
+	archive *Archive
+}
+
+// NewServer wraps archive for gRPC registration.
+func NewServer(archive *Archive) *Server {
+	return &Server{archive: archive}
+}
+
+// ListRingVersions returns every archived version, oldest first.
+func (s *Server) ListRingVersions(ctx context.Context, req *pb.ListRingVersionsRequest) (*pb.ListRingVersionsResponse, error) {
+	entries, err := s.archive.List()
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListRingVersionsResponse{}
+	for _, e := range entries {
+		resp.Versions = append(resp.Versions, &pb.RingVersion{
+			Version:           e.Version,
+			TimestampUnixNano: e.Timestamp.UnixNano(),
+		})
+	}
+	return resp, nil
+}
+
+// GetRingVersion returns the archived entry for a single version.
+func (s *Server) GetRingVersion(ctx context.Context, req *pb.GetRingVersionRequest) (*pb.RingVersion, error) {
+	e, err := s.archive.Get(req.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RingVersion{Version: e.Version, TimestampUnixNano: e.Timestamp.UnixNano()}, nil
+}
+
+// RollbackToVersion restores the active ring/builder files from the
+// archived copy for req.Version.
+func (s *Server) RollbackToVersion(ctx context.Context, req *pb.RollbackToVersionRequest) (*pb.RollbackToVersionResponse, error) {
+	if _, err := s.archive.Rollback(req.Version); err != nil {
+		return nil, err
+	}
+	return &pb.RollbackToVersionResponse{}, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -8,20 +9,37 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 
 	"github.com/gholt/ring"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	pb "github.com/pandemicsyn/syndicate/api/proto"
+	"github.com/pandemicsyn/syndicate/certreloader"
+	"github.com/pandemicsyn/syndicate/consensus"
+	"github.com/pandemicsyn/syndicate/discovery"
+	"github.com/pandemicsyn/syndicate/ftls"
+	"github.com/pandemicsyn/syndicate/ringarchive"
 
 	"log"
 	"net"
+	"net/http"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 var (
 	printVersionInfo = flag.Bool("version", false, "print version/build info")
 )
 
+// HealthCheckInterval is how often slaveHealthCheck re-pushes the ring to
+// every known slave to detect (and recover from) push failures.
+const HealthCheckInterval = 10 * time.Second
+
 var syndVersion string
 var ringVersion string
 var goVersion string
@@ -45,20 +63,25 @@ func Filter(vs []string, f func(string) bool) []string {
 }
 
 func getRingPaths(cfg *Config) (lastBuilder string, lastRing string, err error) {
-	_, err = os.Stat(filepath.Join(cfg.RingDir, "oort.builder"))
-	if err != nil {
-		//TODO: no active builder found, so should we search for the most recent one
-		//we can find and load it and hopefully its matching ring?
-		return "", "", fmt.Errorf("No builder file found in %s", cfg.RingDir)
+	_, builderErr := os.Stat(filepath.Join(cfg.RingDir, "oort.builder"))
+	_, ringErr := os.Stat(filepath.Join(cfg.RingDir, "oort.ring"))
+	if builderErr == nil && ringErr == nil {
+		return filepath.Join(cfg.RingDir, "oort.builder"), filepath.Join(cfg.RingDir, "oort.ring"), nil
 	}
-	lastBuilder = filepath.Join(cfg.RingDir, "oort.builder")
-	_, err = os.Stat(filepath.Join(cfg.RingDir, "oort.ring"))
+
+	// Either the active builder or the active ring (or both) is missing, so
+	// recover a matched archived pair rather than combining whichever
+	// active file does exist with an archived file of a different version:
+	// findLastRing already pairs a builder with its ring by their shared
+	// <stamp>-<version> prefix.
+	lastBuilder, lastRing, err = findLastRing(cfg)
 	if err != nil {
-		//TODO: if we don't find a matching oort.ring should we just
-		// use oort.builder to make new one ?
-		return "", "", fmt.Errorf("No ring file found in %s", cfg.RingDir)
+		return "", "", fmt.Errorf("No builder/ring pair found in %s and no archive to recover from: %v", cfg.RingDir, err)
+	}
+	if lastBuilder == "" || lastRing == "" {
+		return "", "", fmt.Errorf("No builder/ring pair found in %s", cfg.RingDir)
 	}
-	lastRing = filepath.Join(cfg.RingDir, "oort.ring")
+	log.Println("Active builder/ring missing or incomplete, recovering matched pair from archive:", lastBuilder, lastRing)
 	return lastBuilder, lastRing, nil
 }
 
@@ -73,21 +96,46 @@ func findLastRing(cfg *Config) (lastBuilder string, lastRing string, err error)
 		return "", "", err
 	}
 
-	fn := Filter(names, func(v string) bool {
+	builders := Filter(names, func(v string) bool {
 		return strings.HasSuffix(v, "-oort.builder")
 	})
-	sort.Strings(fn)
-	if len(fn) != 0 {
-		lastBuilder = filepath.Join(cfg.RingDir, fn[len(fn)-1])
-	}
-
-	fn = Filter(names, func(v string) bool {
+	rings := Filter(names, func(v string) bool {
 		return strings.HasSuffix(v, "-oort.ring")
 	})
-	if len(fn) != 0 {
-		lastRing = filepath.Join(cfg.RingDir, fn[len(fn)-1])
+	sort.Strings(builders)
+	sort.Strings(rings)
+
+	// Archived pairs share the exact same "<stamp>-<version>" prefix, so
+	// match a builder to its ring by prefix instead of taking the
+	// lexicographically-last name from each list independently, which can
+	// pair a builder with a ring from a different archived version.
+	ringByPrefix := make(map[string]string, len(rings))
+	for _, r := range rings {
+		ringByPrefix[strings.TrimSuffix(r, "-oort.ring")] = r
 	}
-	return lastBuilder, lastRing, nil
+	for i := len(builders) - 1; i >= 0; i-- {
+		prefix := strings.TrimSuffix(builders[i], "-oort.builder")
+		if r, ok := ringByPrefix[prefix]; ok {
+			return filepath.Join(cfg.RingDir, builders[i]), filepath.Join(cfg.RingDir, r), nil
+		}
+	}
+	return "", "", nil
+}
+
+// raftBindAddr derives the address Raft's transport binds on from
+// cfg.AdvertiseAddr (the host:port this node registers with peers), so
+// every node in the cluster gets a distinct, dialable NodeID/BindAddr
+// instead of every node advertising the same hostless ":port".
+func raftBindAddr(cfg *Config) (string, error) {
+	host, port, err := net.SplitHostPort(cfg.AdvertiseAddr)
+	if err != nil {
+		return "", fmt.Errorf("parsing advertise address %q: %v", cfg.AdvertiseAddr, err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", fmt.Errorf("parsing advertise port %q: %v", port, err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(p+1)), nil
 }
 
 func newSyndicateServer(cfg *Config) (*ringmgr, error) {
@@ -117,32 +165,280 @@ func newSyndicateServer(cfg *Config) (*ringmgr, error) {
 	}
 	s.tierlimits = cfg.TierFilter
 	s.managedNodes = bootstrapManagedNodes(s.r)
+	s.archive = ringarchive.New(ringarchive.Config{
+		RingDir:       cfg.RingDir,
+		MaxArchived:   cfg.MaxArchived,
+		MaxArchiveAge: cfg.MaxArchiveAge,
+	})
 	s.changeChan = make(chan *changeMsg, 1)
-	go s.RingChangeManager()
 	s.slaves = cfg.Slaves
+
+	// The ring is now a Raft-replicated state machine rather than a
+	// single-master broadcast: a change only ever takes effect once it's
+	// been committed by quorum (see proposeRingChanges below), leader
+	// election is automatic, and Bootstrap is only true for the one node
+	// standing up a brand new cluster (cfg.RaftBootstrap), not merely
+	// whichever node happens to have an empty slave list.
+	raftBind, err := raftBindAddr(cfg)
+	FatalIf(err, "Couldn't derive raft bind address")
+	s.raft, err = consensus.New(consensus.Config{
+		NodeID:    cfg.AdvertiseAddr,
+		RaftDir:   filepath.Join(cfg.RingDir, "raft"),
+		BindAddr:  raftBind,
+		Bootstrap: cfg.RaftBootstrap,
+	}, s.applyAndArchiveRingChange)
+	FatalIf(err, "Couldn't start raft consensus node")
+	go s.proposeRingChanges()
+
+	s.health = discovery.NewHealthTracker(cfg.UnhealthyThreshold)
+
+	if cfg.SlaveSRV != "" {
+		watcher := discovery.NewWatcher(discovery.NewResolver(), cfg.SlaveSRV, cfg.SlaveSRVInterval, func() []string {
+			s.slavesMu.Lock()
+			defer s.slavesMu.Unlock()
+			return append([]string(nil), s.slaves...)
+		})
+		go watcher.Run(func(added, removed []string) {
+			s.slavesMu.Lock()
+			defer s.slavesMu.Unlock()
+			for _, peer := range added {
+				if err := s.RegisterSlave(peer); err != nil {
+					log.Println("Got error registering discovered peer", peer, ":", err)
+					continue
+				}
+				s.slaves = append(s.slaves, peer)
+			}
+			for _, peer := range removed {
+				if err := s.DeregisterSlave(peer); err != nil {
+					log.Println("Got error deregistering peer", peer, ":", err)
+					continue
+				}
+				s.health.Remove(peer)
+				s.slaves = removeSlave(s.slaves, peer)
+			}
+		})
+	}
+
+	s.slavesMu.Lock()
+	defer s.slavesMu.Unlock()
+
 	if len(s.slaves) == 0 {
-		log.Println("!! Running without slaves, have no one to register !!")
+		log.Println("!! Running without peers, have no one to register !!")
 		return s, nil
 	}
 
-	failcount := 0
-	for _, slave := range s.slaves {
-		if err = s.RegisterSlave(slave); err != nil {
-			log.Println("Got error:", err)
-			failcount++
+	for _, peer := range s.slaves {
+		if err = s.RegisterSlave(peer); err != nil {
+			log.Println("Got error bootstrapping peer", peer, ":", err)
+			s.health.RecordFailure(peer)
+			continue
 		}
+		s.health.RecordSuccess(peer)
 	}
-	if failcount > (len(s.slaves) / 2) {
-		log.Fatalln("More than half of the ring slaves failed to respond. Exiting.")
-	}
+
+	go s.slaveHealthCheck()
 	return s, nil
 }
 
+// RaftApplyTimeout bounds how long proposeRingChanges waits for a ring
+// mutation to be committed by quorum before giving up.
+const RaftApplyTimeout = 10 * time.Second
+
+// applyAndArchiveRingChange is the Raft FSM's ApplyFunc: it's only ever
+// invoked once a ring change has been committed by quorum, at which point
+// it mutates local state via applyRingChange and records the result in the
+// ring archive so operators can list/rollback past versions.
+func (s *ringmgr) applyAndArchiveRingChange(change *consensus.RingChange) error {
+	if err := s.applyRingChange(change); err != nil {
+		return err
+	}
+	if _, err := s.archive.Record(change.Version, change.Ring, change.Builder, time.Now()); err != nil {
+		log.Println("Failed to archive ring version", change.Version, ":", err)
+	}
+	return nil
+}
+
+// changeMsg is one ring mutation submitted to changeChan. result, if
+// non-nil, receives the outcome of proposing it to the Raft log: nil on
+// success, or an error identifying the current leader if this node isn't
+// it, so the API handler that accepted the write can reject it back to the
+// caller instead of it being silently dropped.
+type changeMsg struct {
+	version int64
+	ring    []byte
+	builder []byte
+	result  chan error
+}
+
+// notLeaderError identifies the node a rejected write should be retried
+// against.
+type notLeaderError struct {
+	leader string
+}
+
+func (e *notLeaderError) Error() string {
+	return fmt.Sprintf("not the raft leader, retry against %s", e.leader)
+}
+
+// proposeRingChanges reads ring mutations off changeChan and proposes them
+// to the Raft log instead of applying them locally, so a write only takes
+// effect once a majority of the cluster has acknowledged it. If this node
+// isn't the leader the proposal is rejected rather than dropped: msg.result
+// (when the caller provided one) gets a notLeaderError naming the current
+// leader, so the write can be resubmitted there instead of silently lost.
+func (s *ringmgr) proposeRingChanges() {
+	for msg := range s.changeChan {
+		change := &consensus.RingChange{
+			Version: msg.version,
+			Ring:    msg.ring,
+			Builder: msg.builder,
+		}
+		err := s.raft.Apply(change, RaftApplyTimeout)
+		if err == consensus.ErrNotLeader {
+			err = &notLeaderError{leader: s.raft.Leader()}
+		}
+		if msg.result != nil {
+			msg.result <- err
+			continue
+		}
+		if err != nil {
+			log.Println("Raft apply of ring change failed:", err)
+		}
+	}
+}
+
+// ClusterStatus reports the current slave membership and health, combining
+// ringmgr's tracked peer list with discovery's HealthTracker. It's part of
+// pb.SyndicateServer, so no separate Register call is needed in main().
+func (s *ringmgr) ClusterStatus(ctx context.Context, req *pb.ClusterStatusRequest) (*pb.ClusterStatusResponse, error) {
+	s.slavesMu.Lock()
+	defer s.slavesMu.Unlock()
+	resp := &pb.ClusterStatusResponse{}
+	for _, peer := range s.slaves {
+		resp.Members = append(resp.Members, &pb.ClusterMember{
+			Addr:    peer,
+			Healthy: s.health.IsHealthy(peer),
+		})
+	}
+	return resp, nil
+}
+
+// removeSlave returns slaves with addr removed, preserving order.
+func removeSlave(slaves []string, addr string) []string {
+	out := slaves[:0:0]
+	for _, s := range slaves {
+		if s != addr {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// slaveHealthCheck periodically re-pushes the current ring to every known
+// slave, marking one unhealthy after enough consecutive push failures
+// instead of the old fatal exit when more than half failed at startup.
+func (s *ringmgr) slaveHealthCheck() {
+	for range time.Tick(HealthCheckInterval) {
+		s.slavesMu.Lock()
+		slaves := append([]string(nil), s.slaves...)
+		s.slavesMu.Unlock()
+		for _, peer := range slaves {
+			if err := s.RegisterSlave(peer); err != nil {
+				if s.health.RecordFailure(peer) {
+					log.Println("Slave", peer, "marked unhealthy after repeated push failures")
+				}
+				continue
+			}
+			s.health.RecordSuccess(peer)
+		}
+	}
+}
+
 func newRingDistServer() *ringslave {
 	s := new(ringslave)
 	return s
 }
 
+// ftlsConfig builds the shared ftls.Config for cfg's TLS settings, used for
+// both the grpc.ServerOption and the RegisterSlave identity check below.
+func ftlsConfig(cfg *Config) *ftls.Config {
+	return &ftls.Config{
+		CertFile:   cfg.CertFile,
+		KeyFile:    cfg.KeyFile,
+		CAFile:     cfg.CAFile,
+		ClientAuth: cfg.ClientAuth,
+		SkipVerify: cfg.SkipVerify,
+		ServerName: cfg.ServerName,
+	}
+}
+
+// newTLSServerOpts builds the grpc.ServerOption for cfg's cert/key pair via
+// the shared ftls package, so both the master and slave branches of main()
+// get the same cert reloading and, when CAFile and ClientAuth are set, mutual TLS behavior
+// instead of each duplicating its own TLS bootstrap. The returned Reloader
+// is kept around so a SIGHUP handler can force an immediate reload.
+func newTLSServerOpts(cfg *Config) (grpc.ServerOption, *certreloader.Reloader, error) {
+	return ftls.ServerOption(ftlsConfig(cfg))
+}
+
+// registerSlaveIdentityInterceptor verifies a joining slave's presented
+// client certificate CN/SAN against the address it's registering with
+// before RegisterSlave accepts it, so a valid cert for one slave can't be
+// replayed to register a different one.
+func registerSlaveIdentityInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if info.FullMethod != "/syndicate.Syndicate/RegisterSlave" {
+			return handler(ctx, req)
+		}
+		r, ok := req.(*pb.RegisterSlaveRequest)
+		if !ok {
+			return handler(ctx, req)
+		}
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, fmt.Errorf("RegisterSlave: no peer info on connection")
+		}
+		tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok {
+			return nil, fmt.Errorf("RegisterSlave: connection is not using TLS")
+		}
+		if err := ftls.VerifyPeerIdentity(tlsInfo.State, r.Addr); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// watchSIGHUP forces an immediate cert reload whenever the process receives
+// a SIGHUP, so operators can rotate certs on demand instead of waiting for
+// the watcher or the periodic stat fallback to notice.
+func watchSIGHUP(reloader *certreloader.Reloader) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := reloader.Reload(); err != nil {
+				log.Println("SIGHUP cert reload failed:", err)
+			} else {
+				log.Println("SIGHUP cert reload succeeded")
+			}
+		}
+	}()
+}
+
+// serveMetrics exposes the process's registered Prometheus collectors
+// (including certreloader's reloadsTotal) on addr, so operators can scrape
+// and alert on cert rotation failures instead of only seeing them in logs.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Println("metrics server stopped:", err)
+		}
+	}()
+}
+
 func main() {
 	cfg, err := loadConfig("/etc/oort/syndicate.toml")
 	if err != nil {
@@ -156,20 +452,30 @@ func main() {
 		fmt.Println("go version:", goVersion)
 		return
 	}
+	if cfg.MetricsAddr != "" {
+		serveMetrics(cfg.MetricsAddr)
+		log.Println("Metrics listening on", cfg.MetricsAddr)
+	}
 	if cfg.Master {
 		l, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
 		FatalIf(err, "Failed to bind to port")
 		var opts []grpc.ServerOption
 		if cfg.UseTLS {
-			creds, err := credentials.NewServerTLSFromFile(cfg.CertFile, cfg.KeyFile)
-			FatalIf(err, "Couldn't load cert from file")
-			opts = []grpc.ServerOption{grpc.Creds(creds)}
+			creds, reloader, err := newTLSServerOpts(cfg)
+			FatalIf(err, "Couldn't set up cert reloader")
+			watchSIGHUP(reloader)
+			opts = []grpc.ServerOption{creds}
+			if ftlsConfig(cfg).MutualTLS() {
+				opts = append(opts, grpc.UnaryInterceptor(registerSlaveIdentityInterceptor()))
+			}
 		}
 		s := grpc.NewServer(opts...)
 
 		r, err := newSyndicateServer(cfg)
 		FatalIf(err, "Couldn't prep ring mgr server")
 		pb.RegisterSyndicateServer(s, r)
+		pb.RegisterRingArchiveServer(s, ringarchive.NewServer(r.archive))
+		pb.RegisterConsensusServer(s, consensus.NewServer(r.raft))
 		log.Printf("Master starting up on %d...\n", cfg.Port)
 		s.Serve(l)
 	} else {
@@ -177,9 +483,10 @@ func main() {
 		FatalIf(err, "Failed to bind to port")
 		var opts []grpc.ServerOption
 		if cfg.UseTLS {
-			creds, err := credentials.NewServerTLSFromFile(cfg.CertFile, cfg.KeyFile)
-			FatalIf(err, "Couldn't load cert from file")
-			opts = []grpc.ServerOption{grpc.Creds(creds)}
+			creds, reloader, err := newTLSServerOpts(cfg)
+			FatalIf(err, "Couldn't set up cert reloader")
+			watchSIGHUP(reloader)
+			opts = []grpc.ServerOption{creds}
 		}
 		s := grpc.NewServer(opts...)
 
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func touch(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+}
+
+func TestFindLastRingPairsByVersion(t *testing.T) {
+	dir := t.TempDir()
+	// A builder and ring from the same archived version, plus a newer
+	// builder whose matching ring is missing: findLastRing must fall back
+	// to the complete pair rather than the lexicographically-last builder.
+	touch(t, dir, "1000-1-oort.builder")
+	touch(t, dir, "1000-1-oort.ring")
+	touch(t, dir, "2000-2-oort.builder")
+
+	builder, ring, err := findLastRing(&Config{RingDir: dir})
+	if err != nil {
+		t.Fatalf("findLastRing: %v", err)
+	}
+	wantBuilder := filepath.Join(dir, "1000-1-oort.builder")
+	wantRing := filepath.Join(dir, "1000-1-oort.ring")
+	if builder != wantBuilder || ring != wantRing {
+		t.Fatalf("findLastRing() = (%q, %q), want (%q, %q)", builder, ring, wantBuilder, wantRing)
+	}
+}
+
+func TestFindLastRingPicksNewestCompletePair(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, dir, "1000-1-oort.builder")
+	touch(t, dir, "1000-1-oort.ring")
+	touch(t, dir, "2000-2-oort.builder")
+	touch(t, dir, "2000-2-oort.ring")
+
+	builder, ring, err := findLastRing(&Config{RingDir: dir})
+	if err != nil {
+		t.Fatalf("findLastRing: %v", err)
+	}
+	wantBuilder := filepath.Join(dir, "2000-2-oort.builder")
+	wantRing := filepath.Join(dir, "2000-2-oort.ring")
+	if builder != wantBuilder || ring != wantRing {
+		t.Fatalf("findLastRing() = (%q, %q), want (%q, %q)", builder, ring, wantBuilder, wantRing)
+	}
+}
+
+func TestFindLastRingNoPair(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, dir, "1000-1-oort.builder")
+
+	builder, ring, err := findLastRing(&Config{RingDir: dir})
+	if err != nil {
+		t.Fatalf("findLastRing: %v", err)
+	}
+	if builder != "" || ring != "" {
+		t.Fatalf("findLastRing() = (%q, %q), want (\"\", \"\") with no matched pair", builder, ring)
+	}
+}
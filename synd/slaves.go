@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pandemicsyn/syndicate/consensus"
+)
+
+// DeregisterSlave removes addr from the Raft cluster, undoing whatever
+// RegisterSlave set up for it. It's the dynamic-membership counterpart to
+// RegisterSlave used by the SRV watcher. On a node that is not the Raft
+// leader the Raft configuration is left unchanged and nil is returned.
+// Callers are expected to hold s.slavesMu and to only drop addr from
+// s.slaves once this returns successfully.
+func (s *ringmgr) DeregisterSlave(addr string) error {
+	found := false
+	for _, slave := range s.slaves {
+		if slave == addr {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("DeregisterSlave: %s is not a registered slave", addr)
+	}
+
+	if s.raft != nil {
+		if err := s.raft.Leave(addr); err != nil && err != consensus.ErrNotLeader {
+			log.Println("Failed to remove", addr, "from the raft cluster:", err)
+			return err
+		}
+	}
+	return nil
+}